@@ -2,10 +2,14 @@ package auth
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
+	"sync"
+	"time"
 
 	"golang.org/x/oauth2"
 )
@@ -18,19 +22,53 @@ const (
 
 // Common error definitions
 var (
-	ErrAuthFailed       = errors.New("spotify: authentication failed")
-	ErrNoAccessCode     = errors.New("spotify: no access code received")
-	ErrStateMismatch    = errors.New("spotify: state verification failed")
-	ErrMissingClientID  = errors.New("spotify: client ID is required but not provided")
-	ErrMissingClientSec = errors.New("spotify: client secret is required but not provided")
+	ErrAuthFailed          = errors.New("spotify: authentication failed")
+	ErrNoAccessCode        = errors.New("spotify: no access code received")
+	ErrStateMismatch       = errors.New("spotify: state verification failed")
+	ErrMissingClientID     = errors.New("spotify: client ID is required but not provided")
+	ErrMissingClientSec    = errors.New("spotify: client secret is required but not provided")
+	ErrInvalidRedirectURL  = errors.New("spotify: redirect URL must be a non-empty absolute URL")
+	ErrRefreshTokenRevoked = errors.New("spotify: refresh token revoked or invalid")
+	ErrReservedAuthParam   = errors.New("spotify: authorization parameter is managed by the authenticator")
+	ErrMissingStateStore   = errors.New("spotify: state store is required but not provided")
+	ErrInvalidStateTTL     = errors.New("spotify: state TTL must be positive")
+	ErrInvalidProxyURL     = errors.New("spotify: proxy URL must be an absolute http, https or socks5 URL")
+	ErrInsecureUnsupported = errors.New("spotify: insecure TLS mode requires an *http.Transport")
 )
 
 // Authenticator handles the OAuth2 authentication flow for Spotify.
+// It is safe for concurrent use, including while credentials are rotated
+// with SetClientID and SetClientSecret.
 type Authenticator struct {
-	config *oauth2.Config
-	client *http.Client
+	mu         sync.RWMutex // guards config
+	config     *oauth2.Config
+	creds      *credentials
+	client     *http.Client
+	clientFunc func(context.Context) *http.Client
+	transport  http.RoundTripper
+	timeout    time.Duration
+	jar        http.CookieJar
+	insecure   bool
+	proxyURL   string
+	userAgent  string
+	offline    bool
+	authParams map[string]string
+	stateStore StateStore
+	stateTTL   time.Duration
 }
 
+// TokenExchanger covers the Authenticator methods used to run the
+// authorization code flow. Code that depends on it rather than on
+// *Authenticator can be tested with a fake implementation.
+type TokenExchanger interface {
+	AuthURL(state string, scopes ...string) string
+	Token(ctx context.Context, state string, r *http.Request) (*oauth2.Token, error)
+	Client(ctx context.Context, token *oauth2.Token) *http.Client
+	TokenSource(ctx context.Context, token *oauth2.Token) oauth2.TokenSource
+}
+
+var _ TokenExchanger = (*Authenticator)(nil)
+
 // New creates a new Authenticator with the specified redirect URL and options.
 // By default, it reads client credentials from environment variables:
 // - SPOTIFY_CLIENT_ID
@@ -51,8 +89,11 @@ func New(redirectURL string, opts ...Option) (*Authenticator, error) {
 	}
 
 	auth := &Authenticator{
-		config: cfg,
-		client: http.DefaultClient,
+		config:     cfg,
+		client:     http.DefaultClient,
+		offline:    true,
+		stateStore: NewMemoryStateStore(),
+		stateTTL:   DefaultStateTTL,
 	}
 
 	// Apply all provided options
@@ -71,6 +112,7 @@ func New(redirectURL string, opts ...Option) (*Authenticator, error) {
 	// Set final values
 	auth.config.ClientID = clientID
 	auth.config.ClientSecret = clientSecret
+	auth.creds = &credentials{id: clientID, secret: clientSecret}
 
 	// Validate required fields
 	if auth.config.ClientID == "" {
@@ -79,23 +121,256 @@ func New(redirectURL string, opts ...Option) (*Authenticator, error) {
 	if auth.config.ClientSecret == "" {
 		return nil, ErrMissingClientSec
 	}
+	if err := validateRedirectURL(auth.config.RedirectURL); err != nil {
+		return nil, err
+	}
+	for key := range auth.authParams {
+		if reservedAuthParams[key] {
+			return nil, fmt.Errorf("%w: %s", ErrReservedAuthParam, key)
+		}
+	}
+	if auth.stateStore == nil {
+		return nil, ErrMissingStateStore
+	}
+	if auth.stateTTL <= 0 {
+		return nil, ErrInvalidStateTTL
+	}
+	proxy, err := parseProxyURL(auth.proxyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// A nil client means the default, like everywhere else in net/http
+	if auth.client == nil {
+		auth.client = http.DefaultClient
+	}
+
+	// Apply the transport, timeout and cookie jar to whichever client was configured
+	if auth.transport != nil || auth.timeout > 0 || auth.jar != nil {
+		client := cloneClient(auth.client)
+		if auth.transport != nil {
+			client.Transport = auth.transport
+		}
+		if auth.timeout > 0 {
+			client.Timeout = auth.timeout
+		}
+		if auth.jar != nil {
+			client.Jar = auth.jar
+		}
+		auth.client = client
+	}
+
+	// Proxy and TLS settings can only be set on an *http.Transport; failing here
+	// keeps those options from silently having no effect
+	if (proxy != nil || auth.insecure) && auth.client.Transport != nil {
+		if _, ok := auth.client.Transport.(*http.Transport); !ok {
+			if proxy != nil {
+				return nil, fmt.Errorf("%w: proxy requires an *http.Transport, got %T", ErrInvalidProxyURL, auth.client.Transport)
+			}
+			return nil, fmt.Errorf("%w, got %T", ErrInsecureUnsupported, auth.client.Transport)
+		}
+	}
+
+	// Adjust the proxy and TLS settings of the underlying transport
+	if proxy != nil || auth.insecure {
+		client := cloneClient(auth.client)
+		client.Transport = configureTransport(client.Transport, func(t *http.Transport) {
+			if proxy != nil {
+				t.Proxy = http.ProxyURL(proxy)
+			}
+			// Development only, see WithInsecureSkipVerify
+			if auth.insecure {
+				if t.TLSClientConfig == nil {
+					t.TLSClientConfig = &tls.Config{}
+				}
+				t.TLSClientConfig.InsecureSkipVerify = true
+			}
+		})
+		auth.client = client
+	}
+
+	// Wrap the final transport so the user agent is set on every request
+	if auth.userAgent != "" {
+		client := cloneClient(auth.client)
+		client.Transport = &userAgentTransport{
+			base:      client.Transport,
+			userAgent: auth.userAgent,
+		}
+		auth.client = client
+	}
 
 	return auth, nil
 }
 
+// reservedAuthParams are authorization URL parameters WithAuthParam must not
+// set: those the authenticator sets itself, and the PKCE parameters kept free
+// for a future PKCE flow.
+var reservedAuthParams = map[string]bool{
+	"response_type":         true,
+	"client_id":             true,
+	"redirect_uri":          true,
+	"scope":                 true,
+	"state":                 true,
+	"access_type":           true,
+	"code_challenge":        true,
+	"code_challenge_method": true,
+}
+
+// validateRedirectURL checks that raw is an absolute URL with a host, such as
+// https://example.com/callback or http://localhost:8080/callback.
+func validateRedirectURL(raw string) error {
+	if raw == "" {
+		return ErrInvalidRedirectURL
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidRedirectURL, err)
+	}
+	if !u.IsAbs() || u.Host == "" {
+		return fmt.Errorf("%w: %q", ErrInvalidRedirectURL, raw)
+	}
+
+	return nil
+}
+
+// parseProxyURL parses the URL given to WithProxyURL, returning nil when no
+// proxy is configured.
+func parseProxyURL(raw string) (*url.URL, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidProxyURL, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidProxyURL, raw)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidProxyURL, raw)
+	}
+
+	return u, nil
+}
+
+// WithScopes returns a copy of the authenticator that requests the given
+// scopes. The copy shares the HTTP client, state store and client credentials
+// with the original, so rotating the secret on either one applies to both,
+// but has its own scopes: changing them on one never affects the other.
+func (a *Authenticator) WithScopes(scopes ...string) *Authenticator {
+	cfg := a.configSnapshot()
+	cfg.Scopes = append([]string(nil), scopes...)
+
+	return &Authenticator{
+		config:     cfg,
+		creds:      a.creds,
+		client:     a.client,
+		clientFunc: a.clientFunc,
+		transport:  a.transport,
+		timeout:    a.timeout,
+		jar:        a.jar,
+		insecure:   a.insecure,
+		proxyURL:   a.proxyURL,
+		userAgent:  a.userAgent,
+		offline:    a.offline,
+		authParams: a.authParams,
+		stateStore: a.stateStore,
+		stateTTL:   a.stateTTL,
+	}
+}
+
+// SetClientID replaces the OAuth client ID. Requests started afterwards use
+// the new value, including those made by copies derived with WithScopes;
+// it is safe to call while other requests are in flight.
+func (a *Authenticator) SetClientID(id string) {
+	a.creds.mu.Lock()
+	defer a.creds.mu.Unlock()
+	a.creds.id = id
+}
+
+// SetClientSecret replaces the OAuth client secret, allowing the secret to be
+// rotated without recreating the authenticator. Requests started afterwards
+// use the new value, including those made by copies derived with WithScopes;
+// it is safe to call while other requests are in flight.
+func (a *Authenticator) SetClientSecret(secret string) {
+	a.creds.mu.Lock()
+	defer a.creds.mu.Unlock()
+	a.creds.secret = secret
+}
+
+// credentials holds the client ID and secret shared by an authenticator and
+// the copies derived from it, so a rotation reaches all of them.
+type credentials struct {
+	mu     sync.RWMutex
+	id     string
+	secret string
+}
+
+// httpClient returns the HTTP client to use for requests made with ctx,
+// preferring the one chosen by WithHTTPClientFunc when set.
+func (a *Authenticator) httpClient(ctx context.Context) *http.Client {
+	if a.clientFunc != nil {
+		if client := a.clientFunc(ctx); client != nil {
+			return client
+		}
+	}
+	return a.client
+}
+
+// configSnapshot returns a copy of the current OAuth2 configuration, so it can
+// be used for a request without holding the lock.
+func (a *Authenticator) configSnapshot() *oauth2.Config {
+	a.mu.RLock()
+	cfg := *a.config
+	a.mu.RUnlock()
+
+	a.creds.mu.RLock()
+	cfg.ClientID = a.creds.id
+	cfg.ClientSecret = a.creds.secret
+	a.creds.mu.RUnlock()
+
+	return &cfg
+}
+
 // AuthURL returns the URL to Spotify's authorization page that the user should
 // be directed to in order to authorize the application.
 func (a *Authenticator) AuthURL(state string, scopes ...string) string {
 	// Set scopes for this authorization if provided
 	if len(scopes) > 0 {
+		a.mu.Lock()
 		a.config.Scopes = scopes
+		a.mu.Unlock()
+	}
+	cfg := a.configSnapshot()
+
+	var opts []oauth2.AuthCodeOption
+	if a.offline {
+		opts = append(opts, oauth2.AccessTypeOffline)
 	}
-	return a.config.AuthCodeURL(state, oauth2.AccessTypeOffline)
+	for key, value := range a.authParams {
+		opts = append(opts, oauth2.SetAuthURLParam(key, value))
+	}
+	return cfg.AuthCodeURL(state, opts...)
 }
 
 // Token exchanges the authorization code from the callback for an access token.
 // The state parameter should match the one used in the AuthURL method.
 func (a *Authenticator) Token(ctx context.Context, state string, r *http.Request) (*oauth2.Token, error) {
+	return a.exchange(ctx, r, func(actualState string) error {
+		if actualState != state {
+			return ErrStateMismatch
+		}
+		return nil
+	})
+}
+
+// exchange validates the callback request, checks its state with verifyState
+// and exchanges the authorization code for an access token.
+func (a *Authenticator) exchange(ctx context.Context, r *http.Request, verifyState func(string) error) (*oauth2.Token, error) {
 	values := r.URL.Query()
 
 	// Check for error parameter from Spotify
@@ -110,16 +385,15 @@ func (a *Authenticator) Token(ctx context.Context, state string, r *http.Request
 	}
 
 	// Verify the state matches to prevent CSRF attacks
-	actualState := values.Get("state")
-	if actualState != state {
-		return nil, ErrStateMismatch
+	if err := verifyState(values.Get("state")); err != nil {
+		return nil, err
 	}
 
 	// Use our client for the exchange if provided
-	ctx = context.WithValue(ctx, oauth2.HTTPClient, a.client)
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, a.httpClient(ctx))
 
 	// Exchange the code for a token using the OAuth2 configuration
-	token, err := a.config.Exchange(ctx, code)
+	token, err := a.configSnapshot().Exchange(ctx, code)
 	if err != nil {
 		return nil, fmt.Errorf("spotify: token exchange failed: %w", err)
 	}
@@ -129,15 +403,124 @@ func (a *Authenticator) Token(ctx context.Context, state string, r *http.Request
 
 // Client returns an HTTP client configured with the provided OAuth2 token.
 // This client should be used for authenticated requests to the Spotify API.
+//
+// Besides refreshing the token when it expires, the client refreshes it once
+// and retries the request when Spotify answers 401 Unauthorized, which also
+// covers tokens without a known expiry. Requests whose body cannot be replayed
+// (no GetBody) and tokens without a refresh token are not retried.
 func (a *Authenticator) Client(ctx context.Context, token *oauth2.Token) *http.Client {
-	// Ensure our custom client is used for token-refreshing operations
-	ctx = context.WithValue(ctx, oauth2.HTTPClient, a.client)
-	return a.config.Client(ctx, token)
+	base := a.httpClient(ctx)
+	transport := base.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return &http.Client{
+		Transport: &unauthorizedRetryTransport{
+			base:   transport,
+			source: a.tokenSource(ctx, token),
+		},
+		CheckRedirect: base.CheckRedirect,
+		Jar:           base.Jar,
+		Timeout:       base.Timeout,
+	}
 }
 
 // TokenSource creates an oauth2.TokenSource that refreshes tokens automatically.
+// Each refresh uses the credentials current at that time, so token sources
+// keep working after the client secret is rotated.
+//
+// If Spotify rejects the refresh token with invalid_grant, usually because the
+// user revoked access, the error wraps ErrRefreshTokenRevoked; the stored token
+// should then be discarded and the user asked to log in again.
 func (a *Authenticator) TokenSource(ctx context.Context, token *oauth2.Token) oauth2.TokenSource {
+	return a.tokenSource(ctx, token)
+}
+
+// tokenSource creates the refreshing source behind TokenSource and Client.
+func (a *Authenticator) tokenSource(ctx context.Context, token *oauth2.Token) *reuseTokenSource {
 	// Ensure our custom client is used for token-refreshing operations
-	ctx = context.WithValue(ctx, oauth2.HTTPClient, a.client)
-	return a.config.TokenSource(ctx, token)
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, a.httpClient(ctx))
+
+	refresher := &tokenRefresher{auth: a, ctx: ctx}
+	if token != nil {
+		refresher.refreshToken = token.RefreshToken
+	}
+	return &reuseTokenSource{token: token, refresher: refresher}
+}
+
+// reuseTokenSource returns its cached token while it is valid and refreshes
+// it otherwise, like oauth2.ReuseTokenSource, but can also be forced to
+// refresh a token that Spotify rejected before its expiry.
+type reuseTokenSource struct {
+	mu        sync.Mutex // guards token and serializes refreshes
+	token     *oauth2.Token
+	refresher *tokenRefresher
+}
+
+// Token implements oauth2.TokenSource.
+func (s *reuseTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token.Valid() {
+		return s.token, nil
+	}
+	return s.refreshLocked()
+}
+
+// refresh replaces rejected with a new token, unless a concurrent caller has
+// already replaced it.
+func (s *reuseTokenSource) refresh(rejected *oauth2.Token) (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != rejected && s.token.Valid() {
+		return s.token, nil
+	}
+	return s.refreshLocked()
+}
+
+// canRefresh reports whether the source holds a refresh token.
+func (s *reuseTokenSource) canRefresh() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.refresher.refreshToken != ""
+}
+
+// refreshLocked fetches a new token; s.mu must be held.
+func (s *reuseTokenSource) refreshLocked() (*oauth2.Token, error) {
+	token, err := s.refresher.Token()
+	if err != nil {
+		return nil, err
+	}
+	s.token = token
+	return token, nil
+}
+
+// tokenRefresher is an oauth2.TokenSource that always refreshes, reading the
+// authenticator's configuration afresh on every call.
+// Calls are serialized by the reuseTokenSource that wraps it.
+type tokenRefresher struct {
+	auth         *Authenticator
+	ctx          context.Context
+	refreshToken string
+}
+
+// Token implements oauth2.TokenSource.
+func (r *tokenRefresher) Token() (*oauth2.Token, error) {
+	// An expired token makes the config's source refresh straight away
+	expired := &oauth2.Token{RefreshToken: r.refreshToken}
+	token, err := r.auth.configSnapshot().TokenSource(r.ctx, expired).Token()
+	if err != nil {
+		var retrieveErr *oauth2.RetrieveError
+		if errors.As(err, &retrieveErr) && retrieveErr.ErrorCode == "invalid_grant" {
+			return nil, fmt.Errorf("%w: %w", ErrRefreshTokenRevoked, err)
+		}
+		return nil, err
+	}
+
+	// Spotify may issue a new refresh token along with the access token
+	r.refreshToken = token.RefreshToken
+	return token, nil
 }
@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"time"
 )
@@ -38,19 +40,149 @@ func WithScopes(scopes ...string) Option {
 	}
 }
 
-// WithHTTPClient sets a custom HTTP client for the authenticator.
+// WithOfflineAccess controls whether AuthURL requests offline access
+// (access_type=offline), i.e. a refresh token. It is enabled by default.
+// Without a refresh token, a TokenSource cannot renew the access token once it
+// expires and the user has to authorize again.
+func WithOfflineAccess(enabled bool) Option {
+	return func(a *Authenticator) {
+		a.offline = enabled
+	}
+}
+
+// WithAuthParam adds a custom query parameter to the authorization URL, such as
+// show_dialog=true. Reserved keys cannot be used and make New return
+// ErrReservedAuthParam: the parameters the authenticator sets itself (state,
+// scope, redirect_uri, access_type, ...), plus code_challenge and
+// code_challenge_method, which are held back for a future PKCE flow.
+func WithAuthParam(key, value string) Option {
+	return func(a *Authenticator) {
+		if a.authParams == nil {
+			a.authParams = make(map[string]string)
+		}
+		a.authParams[key] = value
+	}
+}
+
+// WithHTTPClient sets a custom HTTP client for the authenticator. A nil client
+// selects http.DefaultClient.
 func WithHTTPClient(client *http.Client) Option {
 	return func(a *Authenticator) {
 		a.client = client
 	}
 }
 
+// WithHTTPClientFunc selects the HTTP client per request from the request's
+// context, e.g. to route each tenant through its own proxy. When fn is nil or
+// returns nil, the client configured by the other options is used. Clients
+// returned by fn are used as is: WithTimeout, WithUserAgent and the other
+// client options only apply to the static client.
+func WithHTTPClientFunc(fn func(ctx context.Context) *http.Client) Option {
+	return func(a *Authenticator) {
+		a.clientFunc = fn
+	}
+}
+
+// WithTransport sets the RoundTripper used by the authenticator's HTTP client,
+// keeping the client's other settings (timeout, cookie jar) intact. It takes
+// precedence over the transport of a client set via WithHTTPClient, regardless
+// of the order the two options are given in. The user agent from WithUserAgent
+// is always applied outermost, on top of the final transport.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(a *Authenticator) {
+		a.transport = rt
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request made by
+// the authenticator.
+func WithUserAgent(userAgent string) Option {
+	return func(a *Authenticator) {
+		a.userAgent = userAgent
+	}
+}
+
+// WithCookieJar attaches a cookie jar to the authenticator's HTTP client,
+// preserving session cookies across the requests it makes. Like WithTimeout,
+// it keeps the transport of any client set via WithHTTPClient or WithTransport.
+func WithCookieJar(jar http.CookieJar) Option {
+	return func(a *Authenticator) {
+		a.jar = jar
+	}
+}
+
+// WithProxyURL routes the authenticator's requests through the given HTTP,
+// HTTPS or SOCKS5 proxy, e.g. "http://proxy.internal:3128". It sets the Proxy
+// of the configured client's *http.Transport (a clone of http.DefaultTransport
+// if none is set) and keeps its other settings. New returns ErrInvalidProxyURL
+// if the URL cannot be used, or if the configured transport is some other
+// RoundTripper (such as a wrapper passed to WithTransport), since the proxy
+// could not be applied to it.
+func WithProxyURL(proxy string) Option {
+	return func(a *Authenticator) {
+		a.proxyURL = proxy
+	}
+}
+
+// WithInsecureSkipVerify disables TLS certificate verification for requests
+// made by the authenticator.
+//
+// INSECURE: this exposes tokens and client secrets to anyone able to intercept
+// the connection. It exists only to get through TLS-intercepting proxies in
+// development and must never be enabled in production.
+//
+// It applies to the *http.Transport of the configured client (a clone of
+// http.DefaultTransport if none is set), keeping its other settings. New
+// returns ErrInsecureUnsupported if the configured transport is some other
+// RoundTripper (such as a wrapper passed to WithTransport), since TLS
+// verification could not be disabled on it.
+func WithInsecureSkipVerify(enabled bool) Option {
+	return func(a *Authenticator) {
+		a.insecure = enabled
+	}
+}
+
+// WithStateStore sets the store used by AuthURLWithState and TokenWithState.
+// Use a shared store when the login flow spans several server instances.
+// New returns ErrMissingStateStore if store is nil.
+func WithStateStore(store StateStore) Option {
+	return func(a *Authenticator) {
+		a.stateStore = store
+	}
+}
+
+// WithStateTTL sets how long a state created by AuthURLWithState stays valid.
+// New returns ErrInvalidStateTTL if ttl is not positive.
+func WithStateTTL(ttl time.Duration) Option {
+	return func(a *Authenticator) {
+		a.stateTTL = ttl
+	}
+}
+
+// WithAppInfo sets a User-Agent identifying the application, followed by this
+// library, e.g. "my-app/1.2.0 (spotify-api-client-go/v0.3.0)". It replaces any
+// value set with WithUserAgent, and vice versa: the last one given wins.
+func WithAppInfo(name, version string) Option {
+	return func(a *Authenticator) {
+		a.userAgent = fmt.Sprintf("%s/%s (%s)", name, version, libraryUserAgent())
+	}
+}
+
 // WithTimeout sets a timeout for HTTP requests made by the authenticator.
+// It keeps the transport and cookie jar of any client set via WithHTTPClient,
+// regardless of the order the two options are given in.
 func WithTimeout(timeout time.Duration) Option {
 	return func(a *Authenticator) {
-		client := &http.Client{
-			Timeout: timeout,
-		}
-		WithHTTPClient(client)(a)
+		a.timeout = timeout
+	}
+}
+
+// cloneClient returns a shallow copy of c, so options can adjust the client
+// without mutating one owned by the caller (or http.DefaultClient).
+func cloneClient(c *http.Client) *http.Client {
+	if c == nil {
+		return &http.Client{}
 	}
+	clone := *c
+	return &clone
 }
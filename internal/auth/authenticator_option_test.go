@@ -0,0 +1,491 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"runtime/debug"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// roundTripFunc adapts a function to http.RoundTripper.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// newTokenResponse builds a successful token endpoint response.
+func newTokenResponse() *http.Response {
+	tokenResponse := map[string]interface{}{
+		"access_token":  "test-access-token",
+		"token_type":    "Bearer",
+		"refresh_token": "test-refresh-token",
+		"expires_in":    3600,
+	}
+	responseBody, _ := json.Marshal(tokenResponse)
+
+	resp := &http.Response{
+		StatusCode: 200,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(bytes.NewReader(responseBody)),
+	}
+	resp.Header.Set("Content-Type", "application/json")
+	return resp
+}
+
+// newCallbackRequest simulates an incoming OAuth2 callback request.
+func newCallbackRequest(t *testing.T) *http.Request {
+	req, err := http.NewRequest("GET", "http://localhost/callback?state=test-state&code=test-code", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestWithTransport_ComposesWithTimeoutAndUserAgent(t *testing.T) {
+	mockTransport := new(MockRoundTripper)
+	mockTransport.On("RoundTrip", mock.MatchedBy(func(req *http.Request) bool {
+		return req.Header.Get("User-Agent") == "test-app/1.0"
+	})).Return(newTokenResponse(), nil)
+
+	auth, err := New(
+		"http://localhost/callback",
+		WithClientID("test-client-id"),
+		WithClientSecret("test-client-secret"),
+		WithTimeout(5*time.Second),
+		WithTransport(mockTransport),
+		WithUserAgent("test-app/1.0"),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Second, auth.client.Timeout)
+
+	token, err := auth.Token(context.Background(), "test-state", newCallbackRequest(t))
+	assert.NoError(t, err)
+	assert.Equal(t, "test-access-token", token.AccessToken)
+
+	mockTransport.AssertExpectations(t)
+}
+
+func TestWithTransport_DoesNotMutateDefaultClient(t *testing.T) {
+	_, err := New(
+		"http://localhost/callback",
+		WithClientID("test-client-id"),
+		WithClientSecret("test-client-secret"),
+		WithTransport(new(MockRoundTripper)),
+	)
+	assert.NoError(t, err)
+	assert.Nil(t, http.DefaultClient.Transport)
+}
+
+func TestWithTimeout_PreservesCustomClient(t *testing.T) {
+	tests := []struct {
+		name  string
+		order func(client *http.Client) []Option
+	}{
+		{
+			name: "client then timeout",
+			order: func(client *http.Client) []Option {
+				return []Option{WithHTTPClient(client), WithTimeout(5 * time.Second)}
+			},
+		},
+		{
+			name: "timeout then client",
+			order: func(client *http.Client) []Option {
+				return []Option{WithTimeout(5 * time.Second), WithHTTPClient(client)}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockTransport := new(MockRoundTripper)
+			mockTransport.On("RoundTrip", mock.Anything).Return(newTokenResponse(), nil)
+			custom := &http.Client{Transport: mockTransport}
+
+			opts := append([]Option{
+				WithClientID("test-client-id"),
+				WithClientSecret("test-client-secret"),
+			}, tt.order(custom)...)
+
+			auth, err := New("http://localhost/callback", opts...)
+			assert.NoError(t, err)
+			assert.Equal(t, 5*time.Second, auth.client.Timeout)
+			assert.Equal(t, mockTransport, auth.client.Transport)
+			assert.Zero(t, custom.Timeout, "the caller's client must not be mutated")
+
+			_, err = auth.Token(context.Background(), "test-state", newCallbackRequest(t))
+			assert.NoError(t, err)
+			mockTransport.AssertExpectations(t)
+		})
+	}
+}
+
+func TestWithCookieJar_PersistsCookies(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "test-session"})
+		} else {
+			cookie, err := r.Cookie("session")
+			assert.NoError(t, err)
+			if cookie != nil {
+				assert.Equal(t, "test-session", cookie.Value)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"test-access-token","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	jar, err := cookiejar.New(nil)
+	assert.NoError(t, err)
+
+	auth, err := New(
+		"http://localhost/callback",
+		WithClientID("test-client-id"),
+		WithClientSecret("test-client-secret"),
+		WithCookieJar(jar),
+		WithHTTPClient(&http.Client{Transport: http.DefaultTransport}),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, http.DefaultTransport, auth.client.Transport)
+	auth.config.Endpoint.TokenURL = server.URL
+
+	for i := 0; i < 2; i++ {
+		_, err = auth.Token(context.Background(), "test-state", newCallbackRequest(t))
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 2, requests)
+}
+
+func TestWithOfflineAccess(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want bool
+	}{
+		{name: "default", want: true},
+		{name: "enabled", opts: []Option{WithOfflineAccess(true)}, want: true},
+		{name: "disabled", opts: []Option{WithOfflineAccess(false)}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]Option{
+				WithClientID("test-client-id"),
+				WithClientSecret("test-client-secret"),
+			}, tt.opts...)
+
+			auth, err := New("http://localhost/callback", opts...)
+			assert.NoError(t, err)
+
+			u, err := url.Parse(auth.AuthURL("test-state"))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, u.Query().Has("access_type"))
+		})
+	}
+}
+
+type tenantKey struct{}
+
+func TestWithHTTPClientFunc_SelectsClientFromContext(t *testing.T) {
+	defaultTransport := new(MockRoundTripper)
+	defaultTransport.On("RoundTrip", mock.Anything).Return(newTokenResponse(), nil).Once()
+	tenantTransport := new(MockRoundTripper)
+	tenantTransport.On("RoundTrip", mock.Anything).Return(newTokenResponse(), nil).Once()
+	tenantClient := &http.Client{Transport: tenantTransport}
+
+	auth, err := New(
+		"http://localhost/callback",
+		WithClientID("test-client-id"),
+		WithClientSecret("test-client-secret"),
+		WithTransport(defaultTransport),
+		WithHTTPClientFunc(func(ctx context.Context) *http.Client {
+			if ctx.Value(tenantKey{}) == "tenant-a" {
+				return tenantClient
+			}
+			return nil
+		}),
+	)
+	assert.NoError(t, err)
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, "tenant-a")
+	_, err = auth.Token(ctx, "test-state", newCallbackRequest(t))
+	assert.NoError(t, err)
+
+	// No tenant in the context falls back to the static client
+	_, err = auth.Token(context.Background(), "test-state", newCallbackRequest(t))
+	assert.NoError(t, err)
+
+	defaultTransport.AssertExpectations(t)
+	tenantTransport.AssertExpectations(t)
+}
+
+func TestWithAuthParam(t *testing.T) {
+	auth, err := New(
+		"http://localhost/callback",
+		WithClientID("test-client-id"),
+		WithClientSecret("test-client-secret"),
+		WithScopes("user-read-email"),
+		WithAuthParam("show_dialog", "true"),
+	)
+	assert.NoError(t, err)
+
+	u, err := url.Parse(auth.AuthURL("test-state"))
+	assert.NoError(t, err)
+	query := u.Query()
+	assert.Equal(t, "true", query.Get("show_dialog"))
+	assert.Equal(t, "test-state", query.Get("state"))
+	assert.Equal(t, "user-read-email", query.Get("scope"))
+}
+
+func TestWithAuthParam_ReservedKey(t *testing.T) {
+	for _, key := range []string{"state", "scope", "code_challenge"} {
+		_, err := New(
+			"http://localhost/callback",
+			WithClientID("test-client-id"),
+			WithClientSecret("test-client-secret"),
+			WithAuthParam(key, "override"),
+		)
+		assert.ErrorIs(t, err, ErrReservedAuthParam, key)
+	}
+}
+
+func TestWithInsecureSkipVerify(t *testing.T) {
+	custom := &http.Transport{MaxIdleConnsPerHost: 42}
+
+	auth, err := New(
+		"http://localhost/callback",
+		WithClientID("test-client-id"),
+		WithClientSecret("test-client-secret"),
+		WithInsecureSkipVerify(true),
+		WithTransport(custom),
+	)
+	assert.NoError(t, err)
+
+	transport, ok := auth.client.Transport.(*http.Transport)
+	if assert.True(t, ok) {
+		assert.True(t, transport.TLSClientConfig.InsecureSkipVerify)
+		assert.Equal(t, 42, transport.MaxIdleConnsPerHost)
+	}
+	if custom.TLSClientConfig != nil {
+		assert.False(t, custom.TLSClientConfig.InsecureSkipVerify, "the caller's transport must not be mutated")
+	}
+}
+
+func TestWithInsecureSkipVerify_SelfSignedServer(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"test-access-token","token_type":"Bearer","expires_in":3600}`))
+	}))
+	// The rejected handshake is expected, keep it out of the test output
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	for _, insecure := range []bool{false, true} {
+		auth, err := New(
+			"http://localhost/callback",
+			WithClientID("test-client-id"),
+			WithClientSecret("test-client-secret"),
+			WithInsecureSkipVerify(insecure),
+		)
+		assert.NoError(t, err)
+		auth.config.Endpoint.TokenURL = server.URL
+
+		_, err = auth.Token(context.Background(), "test-state", newCallbackRequest(t))
+		if insecure {
+			assert.NoError(t, err)
+		} else {
+			assert.Error(t, err)
+		}
+	}
+}
+
+func TestModuleVersion(t *testing.T) {
+	tests := []struct {
+		name string
+		info debug.BuildInfo
+		want string
+	}{
+		{
+			name: "main module",
+			info: debug.BuildInfo{Main: debug.Module{Path: modulePath, Version: "v0.3.0"}},
+			want: "v0.3.0",
+		},
+		{
+			name: "untagged main module",
+			info: debug.BuildInfo{Main: debug.Module{Path: modulePath, Version: "(devel)"}},
+			want: "devel",
+		},
+		{
+			name: "dependency",
+			info: debug.BuildInfo{
+				Main: debug.Module{Path: "example.com/app", Version: "(devel)"},
+				Deps: []*debug.Module{{Path: modulePath, Version: "v0.4.1"}},
+			},
+			want: "v0.4.1",
+		},
+		{
+			name: "not found",
+			info: debug.BuildInfo{Main: debug.Module{Path: "example.com/app"}},
+			want: "devel",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, moduleVersion(&tt.info))
+		})
+	}
+}
+
+func TestWithAppInfo_ComposesUserAgent(t *testing.T) {
+	want := "test-app/1.0 (" + libraryUserAgent() + ")"
+
+	mockTransport := new(MockRoundTripper)
+	mockTransport.On("RoundTrip", mock.MatchedBy(func(req *http.Request) bool {
+		return req.Header.Get("User-Agent") == want
+	})).Return(newTokenResponse(), nil)
+
+	auth, err := New(
+		"http://localhost/callback",
+		WithClientID("test-client-id"),
+		WithClientSecret("test-client-secret"),
+		WithTransport(mockTransport),
+		WithAppInfo("test-app", "1.0"),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, want, auth.userAgent)
+
+	_, err = auth.Token(context.Background(), "test-state", newCallbackRequest(t))
+	assert.NoError(t, err)
+	mockTransport.AssertExpectations(t)
+}
+
+func TestWithProxyURL_RoutesThroughProxy(t *testing.T) {
+	var proxiedHost string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Requests to a proxy carry the absolute target URL
+		proxiedHost = r.URL.Host
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"test-access-token","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer proxy.Close()
+
+	auth, err := New(
+		"http://localhost/callback",
+		WithClientID("test-client-id"),
+		WithClientSecret("test-client-secret"),
+		WithProxyURL(proxy.URL),
+		WithTimeout(5*time.Second),
+	)
+	assert.NoError(t, err)
+	auth.config.Endpoint.TokenURL = "http://accounts.spotify.invalid/api/token"
+
+	token, err := auth.Token(context.Background(), "test-state", newCallbackRequest(t))
+	assert.NoError(t, err)
+	assert.Equal(t, "test-access-token", token.AccessToken)
+	assert.Equal(t, "accounts.spotify.invalid", proxiedHost)
+	assert.Equal(t, 5*time.Second, auth.client.Timeout)
+}
+
+func TestWithProxyURL_Invalid(t *testing.T) {
+	for _, proxy := range []string{"proxy.internal:3128", "ftp://proxy.internal", "http://", "http://[::1"} {
+		_, err := New(
+			"http://localhost/callback",
+			WithClientID("test-client-id"),
+			WithClientSecret("test-client-secret"),
+			WithProxyURL(proxy),
+		)
+		assert.ErrorIs(t, err, ErrInvalidProxyURL, proxy)
+	}
+}
+
+func TestWithTransport_AppliedAfterHTTPClient(t *testing.T) {
+	mockTransport := new(MockRoundTripper)
+	mockTransport.On("RoundTrip", mock.Anything).Return(newTokenResponse(), nil)
+	custom := &http.Client{Timeout: 5 * time.Second}
+
+	auth, err := New(
+		"http://localhost/callback",
+		WithClientID("test-client-id"),
+		WithClientSecret("test-client-secret"),
+		WithTransport(mockTransport),
+		WithHTTPClient(custom),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, mockTransport, auth.client.Transport)
+	assert.Equal(t, 5*time.Second, auth.client.Timeout)
+	assert.Nil(t, custom.Transport, "the caller's client must not be mutated")
+
+	_, err = auth.Token(context.Background(), "test-state", newCallbackRequest(t))
+	assert.NoError(t, err)
+	mockTransport.AssertExpectations(t)
+}
+
+func TestWithProxyURL_RequiresHTTPTransport(t *testing.T) {
+	wrapper := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return http.DefaultTransport.RoundTrip(req)
+	})
+
+	_, err := New(
+		"http://localhost/callback",
+		WithClientID("test-client-id"),
+		WithClientSecret("test-client-secret"),
+		WithTransport(wrapper),
+		WithProxyURL("http://proxy.internal:3128"),
+	)
+	assert.ErrorIs(t, err, ErrInvalidProxyURL)
+	assert.ErrorContains(t, err, "proxy requires an *http.Transport")
+}
+
+func TestWithProxyURL_NilHTTPClient(t *testing.T) {
+	auth, err := New(
+		"http://localhost/callback",
+		WithClientID("test-client-id"),
+		WithClientSecret("test-client-secret"),
+		WithHTTPClient(nil),
+		WithProxyURL("http://proxy.internal:3128"),
+	)
+	assert.NoError(t, err)
+
+	transport, ok := auth.client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("expected *http.Transport, got %T", auth.client.Transport)
+	}
+	proxy, err := transport.Proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: "accounts.spotify.com"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "http://proxy.internal:3128", proxy.String())
+
+	// The default client must not be modified
+	assert.Nil(t, http.DefaultClient.Transport)
+}
+
+func TestWithInsecureSkipVerify_RequiresHTTPTransport(t *testing.T) {
+	wrapper := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return http.DefaultTransport.RoundTrip(req)
+	})
+
+	_, err := New(
+		"http://localhost/callback",
+		WithClientID("test-client-id"),
+		WithClientSecret("test-client-secret"),
+		WithTransport(wrapper),
+		WithInsecureSkipVerify(true),
+	)
+	assert.ErrorIs(t, err, ErrInsecureUnsupported)
+}
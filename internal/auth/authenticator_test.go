@@ -4,13 +4,19 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"golang.org/x/oauth2"
 )
 
 // MockRoundTripper is a mock for the http.RoundTripper interface
@@ -81,3 +87,186 @@ func TestToken_MockRequest(t *testing.T) {
 	// Assert that the mock transport's RoundTrip method was called
 	mockTransport.AssertExpectations(t)
 }
+
+func TestNew_RedirectURLValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		redirectURL string
+		wantErr     bool
+	}{
+		{name: "https", redirectURL: "https://example.com/callback"},
+		{name: "localhost", redirectURL: "http://localhost:8080/callback"},
+		{name: "loopback", redirectURL: "http://127.0.0.1/callback"},
+		{name: "empty", redirectURL: "", wantErr: true},
+		{name: "relative", redirectURL: "/callback", wantErr: true},
+		{name: "no host", redirectURL: "http:///callback", wantErr: true},
+		{name: "malformed", redirectURL: "http://[::1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(
+				tt.redirectURL,
+				WithClientID("test-client-id"),
+				WithClientSecret("test-client-secret"),
+			)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidRedirectURL)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestAuthenticator_WithScopes(t *testing.T) {
+	auth, err := New(
+		"http://localhost/callback",
+		WithClientID("test-client-id"),
+		WithClientSecret("test-client-secret"),
+		WithScopes("user-read-email"),
+	)
+	assert.NoError(t, err)
+
+	scoped := auth.WithScopes("playlist-read-private", "user-library-read")
+	assert.Equal(t, []string{"user-read-email"}, auth.config.Scopes)
+	assert.Equal(t, []string{"playlist-read-private", "user-library-read"}, scoped.config.Scopes)
+	assert.Same(t, auth.client, scoped.client)
+
+	// Overriding scopes on the copy must not leak into the original
+	scoped.AuthURL("test-state", "user-top-read")
+	assert.Equal(t, []string{"user-read-email"}, auth.config.Scopes)
+	assert.Contains(t, auth.AuthURL("test-state"), "scope=user-read-email")
+}
+
+func TestSetClientSecret_ConcurrentRotation(t *testing.T) {
+	var mu sync.Mutex
+	var lastSecret string
+
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		_, secret, _ := req.BasicAuth()
+		mu.Lock()
+		lastSecret = secret
+		mu.Unlock()
+		return newTokenResponse(), nil
+	})
+
+	auth, err := New(
+		"http://localhost/callback",
+		WithClientID("test-client-id"),
+		WithClientSecret("secret-0"),
+		WithTransport(transport),
+	)
+	assert.NoError(t, err)
+	scoped := auth.WithScopes("user-library-read")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			_, err := auth.Token(context.Background(), "test-state", newCallbackRequest(t))
+			assert.NoError(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := scoped.Token(context.Background(), "test-state", newCallbackRequest(t))
+			assert.NoError(t, err)
+		}()
+		go func(i int) {
+			defer wg.Done()
+			auth.SetClientSecret(fmt.Sprintf("secret-%d", i+1))
+			auth.AuthURL("test-state")
+		}(i)
+	}
+	wg.Wait()
+
+	// Refreshes after the rotation must use the latest secret
+	auth.SetClientSecret("rotated-secret")
+	expired := &oauth2.Token{AccessToken: "old", RefreshToken: "test-refresh-token", Expiry: time.Now().Add(-time.Hour)}
+	token, err := auth.TokenSource(context.Background(), expired).Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "test-access-token", token.AccessToken)
+	assert.Equal(t, "rotated-secret", lastSecret)
+
+	// The derived copy shares the rotated credentials, in both directions
+	_, err = scoped.TokenSource(context.Background(), expired).Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "rotated-secret", lastSecret)
+
+	scoped.SetClientSecret("rotated-again")
+	_, err = auth.TokenSource(context.Background(), expired).Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "rotated-again", lastSecret)
+}
+
+func TestClient_RefreshesOnUnauthorized(t *testing.T) {
+	var refreshes int
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/token" {
+			refreshes++
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token":"fresh-token","token_type":"Bearer"}`))
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if r.Header.Get("Authorization") != "Bearer fresh-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	auth, err := New(
+		"http://localhost/callback",
+		WithClientID("test-client-id"),
+		WithClientSecret("test-client-secret"),
+	)
+	assert.NoError(t, err)
+	auth.config.Endpoint.TokenURL = server.URL + "/api/token"
+
+	// No expiry, so the stale token is only replaced after a 401
+	stale := &oauth2.Token{AccessToken: "stale-token", TokenType: "Bearer", RefreshToken: "test-refresh-token"}
+	client := auth.Client(context.Background(), stale)
+
+	resp, err := client.Post(server.URL+"/v1/me/tracks", "application/json", strings.NewReader(`{"ids":["1"]}`))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 1, refreshes)
+	assert.Equal(t, []string{`{"ids":["1"]}`, `{"ids":["1"]}`}, bodies)
+
+	// The refreshed token is reused afterwards
+	resp, err = client.Get(server.URL + "/v1/me")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 1, refreshes)
+}
+
+func TestClient_UnauthorizedWithoutRefreshToken(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	auth, err := New(
+		"http://localhost/callback",
+		WithClientID("test-client-id"),
+		WithClientSecret("test-client-secret"),
+	)
+	assert.NoError(t, err)
+
+	client := auth.Client(context.Background(), &oauth2.Token{AccessToken: "stale-token", TokenType: "Bearer"})
+	resp, err := client.Get(server.URL + "/v1/me")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, 1, requests)
+}
@@ -0,0 +1,77 @@
+package auth
+
+// Spotify OAuth scopes, see https://developer.spotify.com/documentation/web-api/concepts/scopes
+const (
+	ScopeUGCImageUpload            = "ugc-image-upload"
+	ScopeUserReadPlaybackState     = "user-read-playback-state"
+	ScopeUserModifyPlaybackState   = "user-modify-playback-state"
+	ScopeUserReadCurrentlyPlaying  = "user-read-currently-playing"
+	ScopeAppRemoteControl          = "app-remote-control"
+	ScopeStreaming                 = "streaming"
+	ScopePlaylistReadPrivate       = "playlist-read-private"
+	ScopePlaylistReadCollaborative = "playlist-read-collaborative"
+	ScopePlaylistModifyPrivate     = "playlist-modify-private"
+	ScopePlaylistModifyPublic      = "playlist-modify-public"
+	ScopeUserFollowModify          = "user-follow-modify"
+	ScopeUserFollowRead            = "user-follow-read"
+	ScopeUserReadPlaybackPosition  = "user-read-playback-position"
+	ScopeUserTopRead               = "user-top-read"
+	ScopeUserReadRecentlyPlayed    = "user-read-recently-played"
+	ScopeUserLibraryModify         = "user-library-modify"
+	ScopeUserLibraryRead           = "user-library-read"
+	ScopeUserReadEmail             = "user-read-email"
+	ScopeUserReadPrivate           = "user-read-private"
+)
+
+// Feature is an application capability that requires one or more scopes.
+type Feature int
+
+// Supported features
+const (
+	FeatureReadPlayback Feature = iota
+	FeaturePlaybackControl
+	FeatureReadLibrary
+	FeatureModifyLibrary
+	FeatureReadPlaylists
+	FeatureModifyPlaylists
+	FeatureReadProfile
+)
+
+// featureScopes is the minimal scope set needed by each feature.
+var featureScopes = map[Feature][]string{
+	// Reading what is playing and on which device
+	FeatureReadPlayback: {ScopeUserReadPlaybackState, ScopeUserReadCurrentlyPlaying},
+	// Play, pause, skip, seek, volume and transfer
+	FeaturePlaybackControl: {ScopeUserModifyPlaybackState},
+	// Reading saved tracks, albums, shows and episodes
+	FeatureReadLibrary: {ScopeUserLibraryRead},
+	// Saving and removing library items
+	FeatureModifyLibrary: {ScopeUserLibraryModify},
+	// Listing private and collaborative playlists
+	FeatureReadPlaylists: {ScopePlaylistReadPrivate, ScopePlaylistReadCollaborative},
+	// Creating and editing public and private playlists
+	FeatureModifyPlaylists: {ScopePlaylistModifyPublic, ScopePlaylistModifyPrivate},
+	// Reading the user's profile, country and email address
+	FeatureReadProfile: {ScopeUserReadPrivate, ScopeUserReadEmail},
+}
+
+// ScopesFor returns the scopes needed by the given features, without
+// duplicates and in the order they are first required. The result can be
+// passed to WithScopes:
+//
+//	auth.New(redirectURL, auth.WithScopes(auth.ScopesFor(auth.FeatureReadLibrary, auth.FeaturePlaybackControl)...))
+func ScopesFor(features ...Feature) []string {
+	var scopes []string
+	seen := make(map[string]bool)
+
+	for _, feature := range features {
+		for _, scope := range featureScopes[feature] {
+			if !seen[scope] {
+				seen[scope] = true
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+
+	return scopes
+}
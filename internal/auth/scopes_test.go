@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScopesFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		features []Feature
+		want     []string
+	}{
+		{
+			name:     "none",
+			features: nil,
+			want:     nil,
+		},
+		{
+			name:     "playback control",
+			features: []Feature{FeaturePlaybackControl},
+			want:     []string{ScopeUserModifyPlaybackState},
+		},
+		{
+			name:     "read library",
+			features: []Feature{FeatureReadLibrary},
+			want:     []string{ScopeUserLibraryRead},
+		},
+		{
+			name:     "modify playlists",
+			features: []Feature{FeatureModifyPlaylists},
+			want:     []string{ScopePlaylistModifyPublic, ScopePlaylistModifyPrivate},
+		},
+		{
+			name:     "duplicates removed",
+			features: []Feature{FeatureReadLibrary, FeatureModifyLibrary, FeatureReadLibrary},
+			want:     []string{ScopeUserLibraryRead, ScopeUserLibraryModify},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ScopesFor(tt.features...))
+		})
+	}
+}
+
+func TestScopesFor_AllFeaturesMapped(t *testing.T) {
+	for feature := FeatureReadPlayback; feature <= FeatureReadProfile; feature++ {
+		assert.NotEmpty(t, ScopesFor(feature), "feature %d has no scopes", feature)
+	}
+}
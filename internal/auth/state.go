@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// DefaultStateTTL is how long a state created by AuthURLWithState remains
+// valid when no WithStateTTL option is given.
+const DefaultStateTTL = 10 * time.Minute
+
+// StateStore persists OAuth state values between the authorization redirect
+// and the callback, so that the callback can be served by a different
+// instance than the one that created the URL. Implementations must be safe
+// for concurrent use, and Consume must report true at most once per state.
+//
+// A Redis-backed store can map Put to SET NX with an expiry and Consume to
+// DEL, treating a deleted count of one as a valid state:
+//
+//	func (s *RedisStateStore) Put(ctx context.Context, state string, ttl time.Duration) error {
+//		return s.rdb.SetNX(ctx, "spotify:state:"+state, 1, ttl).Err()
+//	}
+//
+//	func (s *RedisStateStore) Consume(ctx context.Context, state string) (bool, error) {
+//		n, err := s.rdb.Del(ctx, "spotify:state:"+state).Result()
+//		return n == 1, err
+//	}
+type StateStore interface {
+	// Put stores state for at most ttl.
+	Put(ctx context.Context, state string, ttl time.Duration) error
+	// Consume removes state and reports whether it was present and unexpired.
+	Consume(ctx context.Context, state string) (bool, error)
+}
+
+// MemoryStateStore is an in-process StateStore. It is the default store and
+// is only suitable when the whole login flow is served by a single instance.
+type MemoryStateStore struct {
+	mu     sync.Mutex
+	states map[string]time.Time
+	now    func() time.Time
+}
+
+// NewMemoryStateStore creates an empty MemoryStateStore.
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{
+		states: make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+// Put implements StateStore.
+func (s *MemoryStateStore) Put(_ context.Context, state string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+
+	// Drop abandoned states so the map doesn't grow without bound
+	for st, expiry := range s.states {
+		if now.After(expiry) {
+			delete(s.states, st)
+		}
+	}
+
+	s.states[state] = now.Add(ttl)
+	return nil
+}
+
+// Consume implements StateStore.
+func (s *MemoryStateStore) Consume(_ context.Context, state string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiry, ok := s.states[state]
+	if !ok {
+		return false, nil
+	}
+	delete(s.states, state)
+
+	return !s.now().After(expiry), nil
+}
+
+// AuthURLWithState generates a random state, records it in the configured
+// StateStore and returns the authorization URL along with the state.
+// The callback should then be handled with TokenWithState.
+func (a *Authenticator) AuthURLWithState(ctx context.Context, scopes ...string) (string, string, error) {
+	state, err := generateState()
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := a.stateStore.Put(ctx, state, a.stateTTL); err != nil {
+		return "", "", fmt.Errorf("spotify: failed to store state: %w", err)
+	}
+
+	return a.AuthURL(state, scopes...), state, nil
+}
+
+// TokenWithState exchanges the authorization code from the callback for an
+// access token, verifying the callback's state against the StateStore.
+// Each state is accepted only once.
+func (a *Authenticator) TokenWithState(ctx context.Context, r *http.Request) (*oauth2.Token, error) {
+	return a.exchange(ctx, r, func(actualState string) error {
+		if actualState == "" {
+			return ErrStateMismatch
+		}
+
+		ok, err := a.stateStore.Consume(ctx, actualState)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrStateMismatch, err)
+		}
+		if !ok {
+			return ErrStateMismatch
+		}
+
+		return nil
+	})
+}
+
+// generateState returns a random, URL-safe state value.
+func generateState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("spotify: failed to generate state: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
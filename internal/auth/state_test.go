@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMemoryStateStore_ConsumeIsSingleUse(t *testing.T) {
+	store := NewMemoryStateStore()
+	ctx := context.Background()
+
+	assert.NoError(t, store.Put(ctx, "test-state", time.Minute))
+
+	ok, err := store.Consume(ctx, "test-state")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = store.Consume(ctx, "test-state")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestMemoryStateStore_ExpiredState(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryStateStore()
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	assert.NoError(t, store.Put(ctx, "test-state", time.Minute))
+
+	now = now.Add(2 * time.Minute)
+	ok, err := store.Consume(ctx, "test-state")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestTokenWithState(t *testing.T) {
+	mockTransport := new(MockRoundTripper)
+	mockTransport.On("RoundTrip", mock.Anything).Return(newTokenResponse(), nil).Once()
+
+	auth, err := New(
+		"http://localhost/callback",
+		WithClientID("test-client-id"),
+		WithClientSecret("test-client-secret"),
+		WithHTTPClient(&http.Client{Transport: mockTransport}),
+	)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	authURL, state, err := auth.AuthURLWithState(ctx)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, state)
+
+	u, err := url.Parse(authURL)
+	assert.NoError(t, err)
+	assert.Equal(t, state, u.Query().Get("state"))
+
+	callback := "http://localhost/callback?code=test-code&state=" + url.QueryEscape(state)
+	req, err := http.NewRequest("GET", callback, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := auth.TokenWithState(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, "test-access-token", token.AccessToken)
+
+	// Replaying the same callback must fail
+	_, err = auth.TokenWithState(ctx, req)
+	assert.ErrorIs(t, err, ErrStateMismatch)
+
+	mockTransport.AssertExpectations(t)
+}
+
+func TestTokenWithState_UnknownState(t *testing.T) {
+	auth, err := New(
+		"http://localhost/callback",
+		WithClientID("test-client-id"),
+		WithClientSecret("test-client-secret"),
+	)
+	assert.NoError(t, err)
+
+	_, err = auth.TokenWithState(context.Background(), newCallbackRequest(t))
+	assert.ErrorIs(t, err, ErrStateMismatch)
+}
+
+func TestNew_InvalidStateOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  Option
+		want error
+	}{
+		{name: "nil store", opt: WithStateStore(nil), want: ErrMissingStateStore},
+		{name: "zero ttl", opt: WithStateTTL(0), want: ErrInvalidStateTTL},
+		{name: "negative ttl", opt: WithStateTTL(-time.Minute), want: ErrInvalidStateTTL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(
+				"http://localhost/callback",
+				WithClientID("test-client-id"),
+				WithClientSecret("test-client-secret"),
+				tt.opt,
+			)
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+}
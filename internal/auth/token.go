@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// IsExpired reports whether token has passed its expiry time.
+//
+// Token responses that omit expires_in leave Expiry as the zero time. That is
+// treated as "no known expiry" rather than "already expired": IsExpired
+// reports false and the token is never refreshed on a timer. The client
+// returned by Authenticator.Client still refreshes it once Spotify rejects it
+// with 401 Unauthorized.
+func IsExpired(token *oauth2.Token) bool {
+	if token == nil {
+		return true
+	}
+	if token.Expiry.IsZero() {
+		return false
+	}
+	return time.Now().After(token.Expiry)
+}
+
+// ValidToken returns token unchanged while it is still valid, and otherwise
+// refreshes it and returns the new token. Validity follows oauth2.Token.Valid,
+// which treats a token as expired shortly before its Expiry. Callers should
+// persist the returned token whenever it differs from the one passed in.
+func (a *Authenticator) ValidToken(ctx context.Context, token *oauth2.Token) (*oauth2.Token, error) {
+	if token.Valid() {
+		return token, nil
+	}
+	return a.TokenSource(ctx, token).Token()
+}
+
+// StoredToken is a stable, serializable form of an OAuth2 token, including the
+// scopes the user granted. Use it to persist tokens across services instead of
+// relying on the JSON encoding of oauth2.Token.
+type StoredToken struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	TokenType    string    `json:"token_type"`
+	Expiry       time.Time `json:"expiry"`
+	Scopes       []string  `json:"scopes,omitempty"`
+}
+
+// FromOAuth2 converts token into a StoredToken, reading the granted scopes
+// from the token response's scope field.
+func FromOAuth2(token *oauth2.Token) *StoredToken {
+	if token == nil {
+		return nil
+	}
+
+	return &StoredToken{
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		TokenType:    token.TokenType,
+		Expiry:       token.Expiry,
+		Scopes:       grantedScopes(token),
+	}
+}
+
+// ToOAuth2 converts the stored token back into an oauth2.Token, restoring the
+// granted scopes as the token's scope field. A nil StoredToken yields nil.
+func (t *StoredToken) ToOAuth2() *oauth2.Token {
+	if t == nil {
+		return nil
+	}
+
+	token := &oauth2.Token{
+		AccessToken:  t.AccessToken,
+		RefreshToken: t.RefreshToken,
+		TokenType:    t.TokenType,
+		Expiry:       t.Expiry,
+	}
+	if len(t.Scopes) > 0 {
+		token = token.WithExtra(map[string]interface{}{
+			"scope": strings.Join(t.Scopes, " "),
+		})
+	}
+	return token
+}
+
+// grantedScopes returns the space-separated scopes Spotify includes in the
+// token response, or nil when the field is absent.
+func grantedScopes(token *oauth2.Token) []string {
+	scope, _ := token.Extra("scope").(string)
+	return strings.Fields(scope)
+}
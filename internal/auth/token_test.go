@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/oauth2"
+)
+
+func TestIsExpired(t *testing.T) {
+	assert.True(t, IsExpired(nil))
+	assert.False(t, IsExpired(&oauth2.Token{AccessToken: "a"}))
+	assert.False(t, IsExpired(&oauth2.Token{AccessToken: "a", Expiry: time.Now().Add(time.Hour)}))
+	assert.True(t, IsExpired(&oauth2.Token{AccessToken: "a", Expiry: time.Now().Add(-time.Hour)}))
+}
+
+func TestToken_MissingExpiresIn(t *testing.T) {
+	// Token response without expires_in
+	mockResponse := &http.Response{
+		StatusCode: 200,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(bytes.NewReader([]byte(`{"access_token":"test-access-token","token_type":"Bearer"}`))),
+	}
+	mockResponse.Header.Set("Content-Type", "application/json")
+
+	mockTransport := new(MockRoundTripper)
+	mockTransport.On("RoundTrip", mock.Anything).Return(mockResponse, nil)
+
+	auth, err := New(
+		"http://localhost/callback",
+		WithClientID("test-client-id"),
+		WithClientSecret("test-client-secret"),
+		WithTransport(mockTransport),
+	)
+	assert.NoError(t, err)
+
+	token, err := auth.Token(context.Background(), "test-state", newCallbackRequest(t))
+	assert.NoError(t, err)
+	assert.True(t, token.Expiry.IsZero())
+	assert.False(t, IsExpired(token))
+	assert.True(t, token.Valid())
+}
+
+func TestTokenSource_RevokedRefreshToken(t *testing.T) {
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		resp := &http.Response{
+			StatusCode: http.StatusBadRequest,
+			Header:     make(http.Header),
+			Body:       io.NopCloser(bytes.NewReader([]byte(`{"error":"invalid_grant","error_description":"Refresh token revoked"}`))),
+		}
+		resp.Header.Set("Content-Type", "application/json")
+		return resp, nil
+	})
+
+	auth, err := New(
+		"http://localhost/callback",
+		WithClientID("test-client-id"),
+		WithClientSecret("test-client-secret"),
+		WithTransport(transport),
+	)
+	assert.NoError(t, err)
+
+	expired := &oauth2.Token{AccessToken: "old", RefreshToken: "revoked", Expiry: time.Now().Add(-time.Hour)}
+	_, err = auth.TokenSource(context.Background(), expired).Token()
+	assert.ErrorIs(t, err, ErrRefreshTokenRevoked)
+
+	var retrieveErr *oauth2.RetrieveError
+	assert.ErrorAs(t, err, &retrieveErr)
+	assert.Equal(t, "invalid_grant", retrieveErr.ErrorCode)
+}
+
+func TestValidToken(t *testing.T) {
+	mockTransport := new(MockRoundTripper)
+	mockTransport.On("RoundTrip", mock.Anything).Return(newTokenResponse(), nil).Once()
+
+	auth, err := New(
+		"http://localhost/callback",
+		WithClientID("test-client-id"),
+		WithClientSecret("test-client-secret"),
+		WithTransport(mockTransport),
+	)
+	assert.NoError(t, err)
+
+	// A fresh token is returned as is, without a request
+	fresh := &oauth2.Token{AccessToken: "fresh", Expiry: time.Now().Add(time.Hour)}
+	token, err := auth.ValidToken(context.Background(), fresh)
+	assert.NoError(t, err)
+	assert.Same(t, fresh, token)
+
+	// An expired token is refreshed
+	expired := &oauth2.Token{AccessToken: "old", RefreshToken: "test-refresh-token", Expiry: time.Now().Add(-time.Hour)}
+	token, err = auth.ValidToken(context.Background(), expired)
+	assert.NoError(t, err)
+	assert.Equal(t, "test-access-token", token.AccessToken)
+	assert.Equal(t, "test-refresh-token", token.RefreshToken)
+
+	mockTransport.AssertExpectations(t)
+}
+
+func TestStoredToken_RoundTrip(t *testing.T) {
+	mockResponse := &http.Response{
+		StatusCode: 200,
+		Header:     make(http.Header),
+		Body: io.NopCloser(bytes.NewReader([]byte(`{
+			"access_token": "test-access-token",
+			"token_type": "Bearer",
+			"refresh_token": "test-refresh-token",
+			"expires_in": 3600,
+			"scope": "user-read-email user-library-read"
+		}`))),
+	}
+	mockResponse.Header.Set("Content-Type", "application/json")
+
+	mockTransport := new(MockRoundTripper)
+	mockTransport.On("RoundTrip", mock.Anything).Return(mockResponse, nil)
+
+	auth, err := New(
+		"http://localhost/callback",
+		WithClientID("test-client-id"),
+		WithClientSecret("test-client-secret"),
+		WithTransport(mockTransport),
+	)
+	assert.NoError(t, err)
+
+	token, err := auth.Token(context.Background(), "test-state", newCallbackRequest(t))
+	assert.NoError(t, err)
+
+	stored := FromOAuth2(token)
+	assert.Equal(t, []string{"user-read-email", "user-library-read"}, stored.Scopes)
+
+	data, err := json.Marshal(stored)
+	assert.NoError(t, err)
+
+	var decoded StoredToken
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+
+	restored := decoded.ToOAuth2()
+	assert.Equal(t, token.AccessToken, restored.AccessToken)
+	assert.Equal(t, token.RefreshToken, restored.RefreshToken)
+	assert.Equal(t, token.TokenType, restored.TokenType)
+	assert.True(t, token.Expiry.Equal(restored.Expiry))
+	assert.Equal(t, "user-read-email user-library-read", restored.Extra("scope"))
+	assert.Equal(t, stored.Scopes, FromOAuth2(restored).Scopes)
+}
+
+func TestStoredToken_NoScopes(t *testing.T) {
+	assert.Nil(t, FromOAuth2(nil))
+	assert.Nil(t, FromOAuth2(nil).ToOAuth2())
+
+	stored := FromOAuth2(&oauth2.Token{AccessToken: "a", TokenType: "Bearer"})
+	assert.Empty(t, stored.Scopes)
+	assert.Nil(t, stored.ToOAuth2().Extra("scope"))
+}
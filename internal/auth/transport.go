@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"io"
+	"net/http"
+	"runtime/debug"
+
+	"golang.org/x/oauth2"
+)
+
+// modulePath is the module path of this library.
+const modulePath = "github.com/irvifa/spotify-api-client-go"
+
+// libraryUserAgent identifies this library and the version compiled into the
+// running binary, e.g. "spotify-api-client-go/v0.3.0".
+func libraryUserAgent() string {
+	version := "devel"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		version = moduleVersion(info)
+	}
+	return "spotify-api-client-go/" + version
+}
+
+// moduleVersion reports the version of this module recorded in info. The
+// module is the main module when built from its own tree and a dependency
+// otherwise; untagged builds report "devel".
+func moduleVersion(info *debug.BuildInfo) string {
+	version := ""
+	if info.Main.Path == modulePath {
+		version = info.Main.Version
+	} else {
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath {
+				version = dep.Version
+			}
+		}
+	}
+
+	if version == "" || version == "(devel)" {
+		return "devel"
+	}
+	return version
+}
+
+// userAgentTransport sets the User-Agent header before delegating to base.
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the caller's request
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
+
+// configureTransport applies configure to a clone of rt, falling back to
+// http.DefaultTransport when rt is nil, so caller-owned transports are never
+// modified. A RoundTripper that isn't an *http.Transport has no settings to
+// adjust and is returned unchanged; New rejects such transports before
+// settings that need them are applied.
+func configureTransport(rt http.RoundTripper, configure func(*http.Transport)) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	t, ok := rt.(*http.Transport)
+	if !ok {
+		return rt
+	}
+
+	t = t.Clone()
+	configure(t)
+	return t
+}
+
+// unauthorizedRetryTransport authorizes requests with tokens from source. When
+// Spotify answers 401 Unauthorized, it refreshes the token once and retries.
+type unauthorizedRetryTransport struct {
+	base   http.RoundTripper
+	source *reuseTokenSource
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *unauthorizedRetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.source.Token()
+	if err != nil {
+		closeRequestBody(req)
+		return nil, err
+	}
+
+	resp, err := t.send(req, req.Body, token)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	// Retrying needs a replayable body and a way to get a new token
+	if (req.Body != nil && req.GetBody == nil) || !t.source.canRefresh() {
+		return resp, nil
+	}
+
+	token, err = t.source.refresh(token)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	body := req.Body
+	if req.GetBody != nil {
+		if body, err = req.GetBody(); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+	}
+
+	// Drain the rejected response so its connection can be reused
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	return t.send(req, body, token)
+}
+
+// send issues a copy of req with the given body, authorized with token.
+func (t *unauthorizedRetryTransport) send(req *http.Request, body io.ReadCloser, token *oauth2.Token) (*http.Response, error) {
+	// A RoundTripper must not modify the caller's request
+	clone := req.Clone(req.Context())
+	clone.Body = body
+	token.SetAuthHeader(clone)
+	return t.base.RoundTrip(clone)
+}
+
+// closeRequestBody closes the request body, as a RoundTripper must do even
+// when it returns an error.
+func closeRequestBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}